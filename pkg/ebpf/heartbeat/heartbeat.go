@@ -0,0 +1,110 @@
+// Package heartbeat tracks liveness of the event pipeline via named Probes
+// from each pipeline stage, rather than a bare scheduler tick.
+package heartbeat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lastProgressDesc backs the tracee_heartbeat_last_progress_seconds gauge, one series per probe.
+var lastProgressDesc = prometheus.NewDesc(
+	"tracee_heartbeat_last_progress_seconds",
+	"Seconds since this pipeline stage last reported progress to the heartbeat.",
+	[]string{"stage"},
+	nil,
+)
+
+// Heartbeat aggregates liveness Probes from every pipeline stage.
+type Heartbeat struct {
+	probes []*Probe
+}
+
+var (
+	instanceMu sync.Mutex
+	instance   *Heartbeat
+)
+
+// Init creates the package-level Heartbeat instance, starts draining each
+// probe's tick channel, and registers its gauge. Retrieve the result with GetInstance.
+func Init(ctx context.Context, probes ...*Probe) *Heartbeat {
+	h := &Heartbeat{probes: probes}
+
+	for _, p := range probes {
+		go p.drain(ctx)
+	}
+
+	instanceMu.Lock()
+	if instance != nil {
+		prometheus.Unregister(instance)
+	}
+	instance = h
+	instanceMu.Unlock()
+
+	prometheus.MustRegister(h)
+
+	return h
+}
+
+// GetInstance returns the Heartbeat created by the most recent Init call, or nil.
+func GetInstance() *Heartbeat {
+	instanceMu.Lock()
+	defer instanceMu.Unlock()
+
+	return instance
+}
+
+// IsAlive reports whether every tracked probe has progressed within its
+// stall threshold.
+func (h *Heartbeat) IsAlive() bool {
+	for _, p := range h.probes {
+		if p.stalled() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Status is the liveness state of a single probe.
+type Status struct {
+	Name         string
+	LastProgress time.Time
+	Stalled      bool
+}
+
+// Statuses returns the current status of every tracked probe, for
+// /healthz?verbose=1.
+func (h *Heartbeat) Statuses() []Status {
+	statuses := make([]Status, 0, len(h.probes))
+
+	for _, p := range h.probes {
+		statuses = append(statuses, Status{
+			Name:         p.Name,
+			LastProgress: p.LastProgress(),
+			Stalled:      p.stalled(),
+		})
+	}
+
+	return statuses
+}
+
+// Describe implements prometheus.Collector.
+func (h *Heartbeat) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastProgressDesc
+}
+
+// Collect implements prometheus.Collector.
+func (h *Heartbeat) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range h.probes {
+		ch <- prometheus.MustNewConstMetric(
+			lastProgressDesc,
+			prometheus.GaugeValue,
+			time.Since(p.LastProgress()).Seconds(),
+			p.Name,
+		)
+	}
+}
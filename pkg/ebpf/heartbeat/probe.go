@@ -0,0 +1,61 @@
+package heartbeat
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Probe is a named, liveness-tracked stage of the event pipeline. The owning
+// goroutine calls Tick whenever it makes progress.
+type Probe struct {
+	// Name identifies the stage in /healthz?verbose=1 and the heartbeat gauge.
+	Name string
+	// StallThreshold is how long this probe may go without a Tick before it's considered stalled.
+	StallThreshold time.Duration
+
+	tick     chan struct{}
+	lastSeen atomic.Int64 // UnixNano
+}
+
+// NewProbe creates a Probe ready to be passed to Init.
+func NewProbe(name string, stallThreshold time.Duration) *Probe {
+	p := &Probe{
+		Name:           name,
+		StallThreshold: stallThreshold,
+		tick:           make(chan struct{}, 1),
+	}
+	p.lastSeen.Store(time.Now().UnixNano())
+
+	return p
+}
+
+// Tick records that the stage made progress. Safe to call from any goroutine; never blocks.
+func (p *Probe) Tick() {
+	select {
+	case p.tick <- struct{}{}:
+	default:
+	}
+}
+
+// LastProgress returns the last time Tick was observed to fire.
+func (p *Probe) LastProgress() time.Time {
+	return time.Unix(0, p.lastSeen.Load())
+}
+
+// stalled reports whether this probe hasn't ticked within StallThreshold.
+func (p *Probe) stalled() bool {
+	return time.Since(p.LastProgress()) > p.StallThreshold
+}
+
+// drain runs until ctx is done, recording the time of every Tick.
+func (p *Probe) drain(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.tick:
+			p.lastSeen.Store(time.Now().UnixNano())
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package heartbeat
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProbeStallDetection(t *testing.T) {
+	p := NewProbe("test-stage", 20*time.Millisecond)
+
+	if p.stalled() {
+		t.Fatalf("freshly created probe reports stalled")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !p.stalled() {
+		t.Fatalf("probe with no Tick past StallThreshold reports not stalled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.drain(ctx)
+
+	p.Tick()
+	// give drain a moment to observe the tick.
+	time.Sleep(5 * time.Millisecond)
+
+	if p.stalled() {
+		t.Fatalf("probe reports stalled right after Tick")
+	}
+}
+
+func TestProbeTickNeverBlocks(t *testing.T) {
+	p := NewProbe("test-stage", time.Second)
+
+	// Tick with no drain goroutine running: the buffered channel must
+	// absorb one pending tick without blocking the caller.
+	done := make(chan struct{})
+	go func() {
+		p.Tick()
+		p.Tick()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Tick blocked with no drain goroutine running")
+	}
+}
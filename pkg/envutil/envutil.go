@@ -0,0 +1,50 @@
+// Package envutil applies environment-variable overrides to already-parsed config.
+package envutil
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// String returns the value of the environment variable key, or fallback if
+// key is unset.
+func String(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+
+	return fallback
+}
+
+// Duration returns the environment variable key parsed with
+// time.ParseDuration, or fallback if key is unset or unparsable.
+func Duration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+
+	return d
+}
+
+// Int returns the environment variable key parsed with strconv.Atoi, or
+// fallback if key is unset or unparsable.
+func Int(key string, fallback int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+
+	return i
+}
@@ -0,0 +1,48 @@
+package envutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	t.Setenv("ENVUTIL_TEST_STRING", "set")
+	if got := String("ENVUTIL_TEST_STRING", "fallback"); got != "set" {
+		t.Errorf("String() = %q, want %q", got, "set")
+	}
+	if got := String("ENVUTIL_TEST_STRING_UNSET", "fallback"); got != "fallback" {
+		t.Errorf("String() = %q, want %q", got, "fallback")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Setenv("ENVUTIL_TEST_DURATION", "5s")
+	if got := Duration("ENVUTIL_TEST_DURATION", time.Second); got != 5*time.Second {
+		t.Errorf("Duration() = %v, want %v", got, 5*time.Second)
+	}
+
+	t.Setenv("ENVUTIL_TEST_DURATION_BAD", "not-a-duration")
+	if got := Duration("ENVUTIL_TEST_DURATION_BAD", time.Second); got != time.Second {
+		t.Errorf("Duration() with unparsable value = %v, want fallback %v", got, time.Second)
+	}
+
+	if got := Duration("ENVUTIL_TEST_DURATION_UNSET", time.Second); got != time.Second {
+		t.Errorf("Duration() with unset key = %v, want fallback %v", got, time.Second)
+	}
+}
+
+func TestInt(t *testing.T) {
+	t.Setenv("ENVUTIL_TEST_INT", "42")
+	if got := Int("ENVUTIL_TEST_INT", 7); got != 42 {
+		t.Errorf("Int() = %d, want %d", got, 42)
+	}
+
+	t.Setenv("ENVUTIL_TEST_INT_BAD", "not-an-int")
+	if got := Int("ENVUTIL_TEST_INT_BAD", 7); got != 7 {
+		t.Errorf("Int() with unparsable value = %d, want fallback %d", got, 7)
+	}
+
+	if got := Int("ENVUTIL_TEST_INT_UNSET", 7); got != 7 {
+		t.Errorf("Int() with unset key = %d, want fallback %d", got, 7)
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"runtime/pprof"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithPprofLabels returns a copy of ctx carrying labels as pprof profiler labels.
+func WithPprofLabels(ctx context.Context, labels ...string) context.Context {
+	return pprof.WithLabels(ctx, pprof.Labels(labels...))
+}
+
+// Do runs fn with ctx's pprof labels applied to the current goroutine.
+func Do(ctx context.Context, fn func(ctx context.Context)) {
+	pprof.Do(ctx, labelSetOf(ctx), fn)
+}
+
+// labelSetOf reads back the pprof labels ctx carries.
+func labelSetOf(ctx context.Context) pprof.LabelSet {
+	var kvs []string
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		kvs = append(kvs, key, value)
+		return true
+	})
+	return pprof.Labels(kvs...)
+}
+
+// exemplarFromContext converts the pprof label set carried by ctx into a
+// prometheus exemplar attachment, trimmed to fit prometheus.ExemplarMaxRunes.
+func exemplarFromContext(ctx context.Context) prometheus.Labels {
+	labels := prometheus.Labels{}
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+
+	return fitExemplar(labels)
+}
+
+// fitExemplar drops labels, in sorted-key order, once the combined rune
+// count would exceed prometheus.ExemplarMaxRunes and panic AddWithExemplar/ObserveWithExemplar.
+func fitExemplar(labels prometheus.Labels) prometheus.Labels {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fitted := prometheus.Labels{}
+	total := 0
+
+	for _, k := range keys {
+		v := labels[k]
+		pairLen := utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+
+		if total+pairLen > prometheus.ExemplarMaxRunes {
+			continue
+		}
+
+		fitted[k] = v
+		total += pairLen
+	}
+
+	if len(fitted) == 0 {
+		return nil
+	}
+
+	return fitted
+}
+
+// IncCounterWithExemplar increments counter by one, attaching ctx's pprof
+// label set as an OpenMetrics exemplar when the counter supports it.
+func IncCounterWithExemplar(ctx context.Context, counter prometheus.Counter) {
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+		adder.AddWithExemplar(1, exemplarFromContext(ctx))
+		return
+	}
+
+	counter.Inc()
+}
+
+// ObserveHistogramWithExemplar observes value on histogram, attaching ctx's
+// pprof label set as an OpenMetrics exemplar when the histogram supports it.
+func ObserveHistogramWithExemplar(ctx context.Context, histogram prometheus.Histogram, value float64) {
+	if obs, ok := histogram.(prometheus.ExemplarObserver); ok {
+		obs.ObserveWithExemplar(value, exemplarFromContext(ctx))
+		return
+	}
+
+	histogram.Observe(value)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFitExemplar(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels prometheus.Labels
+		want   prometheus.Labels
+	}{
+		{
+			name:   "empty labels",
+			labels: prometheus.Labels{},
+			want:   nil,
+		},
+		{
+			name:   "well under the limit keeps everything",
+			labels: prometheus.Labels{"event": "execve", "policy": "default"},
+			want:   prometheus.Labels{"event": "execve", "policy": "default"},
+		},
+		{
+			name: "drops labels in sorted-key order once over the limit",
+			labels: prometheus.Labels{
+				"container_id": stringOfLen(64),
+				"event":        stringOfLen(60),
+				"policy":       stringOfLen(60),
+			},
+			// keys sorted: container_id, event, policy. container_id (12+64=76)
+			// fits alone; event (5+60=65) would push total to 141 > 128, so it's
+			// dropped; policy is evaluated independently and also dropped since
+			// 76+66=142 > 128.
+			want: prometheus.Labels{"container_id": stringOfLen(64)},
+		},
+		{
+			name: "counts runes, not bytes, for multi-byte label values",
+			// "日" is 1 rune but 3 bytes: 50 of them is 150 bytes (over
+			// prometheus.ExemplarMaxRunes if miscounted as bytes) but only 50 runes
+			// (well under it), so this only passes once fitExemplar counts
+			// runes rather than len().
+			labels: prometheus.Labels{"event": stringOfRunes('日', 50)},
+			want:   prometheus.Labels{"event": stringOfRunes('日', 50)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitExemplar(tt.labels)
+			if len(got) != len(tt.want) {
+				t.Fatalf("fitExemplar() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("fitExemplar()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func stringOfLen(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = 'a'
+	}
+	return string(b)
+}
+
+func stringOfRunes(r rune, n int) string {
+	rs := make([]rune, n)
+	for i := range rs {
+		rs[i] = r
+	}
+	return string(rs)
+}
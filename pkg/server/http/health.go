@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/ebpf/heartbeat"
+)
+
+// HealthChecker is implemented by subsystems that want to take part in Tracee's readiness check.
+type HealthChecker interface {
+	// Name identifies the checker in the /readyz JSON body.
+	Name() string
+	// Check returns nil if the subsystem is ready, or an error describing why it isn't.
+	Check(ctx context.Context) error
+}
+
+// checkResult is the per-check status reported in a /healthz or /readyz body.
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport is the JSON body served by /healthz and /readyz.
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// healthRegistry runs a set of named HealthCheckers and renders their combined result as a healthReport.
+type healthRegistry struct {
+	mu       sync.RWMutex
+	checkers []HealthChecker
+}
+
+// register adds checker to the registry.
+func (r *healthRegistry) register(checker HealthChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers = append(r.checkers, checker)
+}
+
+// run executes every registered checker not listed in exclude, concurrently.
+func (r *healthRegistry) run(ctx context.Context, exclude map[string]bool) (bool, map[string]checkResult) {
+	r.mu.RLock()
+	checkers := make([]HealthChecker, len(r.checkers))
+	copy(checkers, r.checkers)
+	r.mu.RUnlock()
+
+	results := make(map[string]checkResult, len(checkers))
+	ok := true
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, c := range checkers {
+		if exclude[c.Name()] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(c HealthChecker) {
+			defer wg.Done()
+
+			res := checkResult{Status: "pass"}
+			if err := c.Check(ctx); err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.Name()] = res
+			if res.Status == "fail" {
+				ok = false
+			}
+			mu.Unlock()
+		}(c)
+	}
+
+	wg.Wait()
+
+	return ok, results
+}
+
+// ServeHTTP renders the registry's health report, honouring the `verbose` and `exclude` query parameters.
+func (r *healthRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	exclude := make(map[string]bool)
+	for _, name := range req.URL.Query()["exclude"] {
+		exclude[name] = true
+	}
+
+	ok, results := r.run(req.Context(), exclude)
+
+	report := healthReport{Status: "pass"}
+	if !ok {
+		report.Status = "fail"
+	}
+
+	if req.URL.Query().Get("verbose") == "1" {
+		report.Checks = results
+	} else if !ok {
+		// always explain a failure, even without ?verbose=1
+		failed := make(map[string]checkResult)
+		for name, res := range results {
+			if res.Status == "fail" {
+				failed[name] = res
+			}
+		}
+		report.Checks = failed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// heartbeatProbeChecker adapts a single heartbeat.Probe to the HealthChecker interface.
+type heartbeatProbeChecker struct {
+	probe *heartbeat.Probe
+}
+
+func (c heartbeatProbeChecker) Name() string {
+	return c.probe.Name
+}
+
+func (c heartbeatProbeChecker) Check(ctx context.Context) error {
+	lastProgress := c.probe.LastProgress()
+	if time.Since(lastProgress) <= c.probe.StallThreshold {
+		return nil
+	}
+
+	return fmt.Errorf("no progress since %s (stall threshold %s)",
+		lastProgress.Format(time.RFC3339), c.probe.StallThreshold)
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeChecker struct {
+	name string
+	err  error
+}
+
+func (f fakeChecker) Name() string                    { return f.name }
+func (f fakeChecker) Check(ctx context.Context) error { return f.err }
+
+func TestHealthRegistryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		checkers []fakeChecker
+		exclude  map[string]bool
+		wantOK   bool
+		wantLen  int
+	}{
+		{
+			name:     "all pass",
+			checkers: []fakeChecker{{name: "a"}, {name: "b"}},
+			wantOK:   true,
+			wantLen:  2,
+		},
+		{
+			name:     "one fails",
+			checkers: []fakeChecker{{name: "a"}, {name: "b", err: errors.New("stalled")}},
+			wantOK:   false,
+			wantLen:  2,
+		},
+		{
+			name:     "excluded checker is skipped entirely",
+			checkers: []fakeChecker{{name: "a"}, {name: "b", err: errors.New("stalled")}},
+			exclude:  map[string]bool{"b": true},
+			wantOK:   true,
+			wantLen:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r healthRegistry
+			for _, c := range tt.checkers {
+				r.register(c)
+			}
+
+			ok, results := r.run(context.Background(), tt.exclude)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if len(results) != tt.wantLen {
+				t.Errorf("len(results) = %d, want %d", len(results), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestHealthRegistryServeHTTP(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkers      []fakeChecker
+		url           string
+		wantStatus    int
+		wantChecks    bool
+		wantNumChecks int
+	}{
+		{
+			name:       "default hides checks on pass",
+			checkers:   []fakeChecker{{name: "ok-checker"}},
+			url:        "/healthz",
+			wantStatus: 200,
+			wantChecks: false,
+		},
+		{
+			name:          "verbose always shows all checks",
+			checkers:      []fakeChecker{{name: "ok-checker"}, {name: "bad-checker", err: errors.New("no progress")}},
+			url:           "/healthz?verbose=1",
+			wantStatus:    503,
+			wantChecks:    true,
+			wantNumChecks: 2,
+		},
+		{
+			name:       "exclude drops the failing checker",
+			checkers:   []fakeChecker{{name: "ok-checker"}, {name: "bad-checker", err: errors.New("no progress")}},
+			url:        "/healthz?exclude=bad-checker",
+			wantStatus: 200,
+			wantChecks: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r healthRegistry
+			for _, c := range tt.checkers {
+				r.register(c)
+			}
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var report healthReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+
+			if tt.wantChecks && len(report.Checks) != tt.wantNumChecks {
+				t.Errorf("len(report.Checks) = %d, want %d", len(report.Checks), tt.wantNumChecks)
+			}
+		})
+	}
+}
+
+func TestHealthRegistryServeHTTPFailsWithoutVerbose(t *testing.T) {
+	var r healthRegistry
+	r.register(fakeChecker{name: "ok-checker"})
+	r.register(fakeChecker{name: "bad-checker", err: errors.New("no progress")})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != 503 {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+
+	var report healthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if report.Status != "fail" {
+		t.Errorf("report.Status = %q, want fail", report.Status)
+	}
+
+	// a failure is explained even without ?verbose=1, but only the failing check.
+	if len(report.Checks) != 1 {
+		t.Fatalf("len(report.Checks) = %d, want 1", len(report.Checks))
+	}
+	if _, ok := report.Checks["bad-checker"]; !ok {
+		t.Errorf("report.Checks missing bad-checker")
+	}
+}
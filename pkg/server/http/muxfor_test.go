@@ -0,0 +1,29 @@
+package http
+
+import "testing"
+
+func TestMuxForSharesListenerPerAddress(t *testing.T) {
+	s := New(Config{})
+
+	a := s.muxFor(":9000")
+	b := s.muxFor(":9000")
+	if a != b {
+		t.Fatalf("muxFor(same address) returned different muxes")
+	}
+
+	c := s.muxFor(":9001")
+	if a == c {
+		t.Fatalf("muxFor(different address) returned the same mux")
+	}
+
+	if len(s.listeners) != 2 {
+		t.Fatalf("len(s.listeners) = %d, want 2", len(s.listeners))
+	}
+}
+
+func TestNewAppliesDefaultShutdownTimeout(t *testing.T) {
+	s := New(Config{})
+	if s.shutdownTimeout != defaultShutdownTimeout {
+		t.Errorf("shutdownTimeout = %v, want default %v", s.shutdownTimeout, defaultShutdownTimeout)
+	}
+}
@@ -0,0 +1,365 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/pyroscope-go"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// snapshotCPUDuration bounds how long a /debug/pprof/snapshot CPU capture runs.
+const snapshotCPUDuration = 10 * time.Second
+
+// snapshotMu serializes every caller of runtime/pprof.StartCPUProfile in
+// this package, which only allows one CPU profile at a time.
+var snapshotMu sync.Mutex
+
+// ErrProfilingBusy is returned when the runtime's CPU profiler is already
+// held by a collector this package doesn't coordinate with, most notably
+// push mode's own pyroscope-go collector. Treat it as transient and retry.
+var ErrProfilingBusy = errors.New("cpu profiling already in use, retry shortly")
+
+// Profiler is a pluggable continuous-profiling backend. Push-mode Pyroscope,
+// pull-mode scraping and the OTLP exporter all implement it so Server can
+// treat them uniformly.
+type Profiler interface {
+	// Start begins continuous profiling (or registers for scraping).
+	Start() error
+	// Stop releases any resources Start acquired.
+	Stop() error
+	// Snapshot captures an ad-hoc CPU+heap profile and returns an ID the
+	// operator can attach to an incident ticket. The OTLP backend uploads
+	// the capture to its collector; push and pull keep it in memory for
+	// GET /debug/pprof/snapshot/{id} and do not upload it anywhere.
+	Snapshot(ctx context.Context) (string, error)
+}
+
+// ProfilerConfig configures whichever Profiler backend EnableProfiler selects.
+type ProfilerConfig struct {
+	// Backend selects the implementation: "push" (default), "pull" or "otlp".
+	Backend string
+	// ApplicationName tags the profiles with the reporting application.
+	ApplicationName string
+	// ServerAddress is the Pyroscope server push mode sends profiles to. The
+	// pull and OTLP backends do not use it.
+	ServerAddress string
+	// AuthToken authenticates push-mode uploads.
+	AuthToken string
+	// Tags are attached to every profile push mode sends. Pull mode only logs
+	// them when it starts; the OTLP backend and snapshots do not carry them.
+	Tags map[string]string
+	// UploadRate controls how often push mode ships profiles.
+	UploadRate time.Duration
+	// ProfileTypes selects which profiles push mode collects (cpu, alloc_objects, ...).
+	ProfileTypes []string
+	// OTLPEndpoint is the collector address profiles are shipped to in OTLP mode.
+	OTLPEndpoint string
+}
+
+// newProfiler builds the Profiler backend selected by cfg.Backend.
+func newProfiler(cfg ProfilerConfig, store *snapshotStore) Profiler {
+	switch cfg.Backend {
+	case "otlp":
+		return &otlpProfiler{cfg: cfg}
+	case "pull":
+		return &pullProfiler{cfg: cfg, store: store}
+	default:
+		return &pushProfiler{cfg: cfg, store: store}
+	}
+}
+
+// snapshot is the ad-hoc CPU+heap capture taken by takeSnapshot, kept as two
+// independent gzip-encoded pprof protobufs rather than merged into one.
+type snapshot struct {
+	id   string
+	cpu  []byte
+	heap []byte
+}
+
+// takeSnapshot captures a CPU profile for snapshotCPUDuration (or until ctx
+// is done, whichever comes first) followed by a heap profile, and assigns
+// the pair a random ID to hand back to the caller.
+func takeSnapshot(ctx context.Context) (snapshot, error) {
+	var idBytes [8]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return snapshot{}, err
+	}
+	id := hex.EncodeToString(idBytes[:])
+
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+
+	var cpuBuf bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpuBuf); err != nil {
+		if strings.Contains(err.Error(), "already in use") {
+			return snapshot{}, fmt.Errorf("%w: %v", ErrProfilingBusy, err)
+		}
+
+		return snapshot{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(snapshotCPUDuration):
+	}
+
+	pprof.StopCPUProfile()
+
+	var heapBuf bytes.Buffer
+	if err := pprof.WriteHeapProfile(&heapBuf); err != nil {
+		return snapshot{}, err
+	}
+
+	return snapshot{id: id, cpu: cpuBuf.Bytes(), heap: heapBuf.Bytes()}, nil
+}
+
+// snapshotStore holds captured snapshots in memory so they can be retrieved
+// later via GET /debug/pprof/snapshot/{id}.
+type snapshotStore struct {
+	mu   sync.Mutex
+	byID map[string]snapshot
+}
+
+func (s *snapshotStore) put(snap snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byID == nil {
+		s.byID = make(map[string]snapshot)
+	}
+	s.byID[snap.id] = snap
+}
+
+func (s *snapshotStore) get(id string) (snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, ok := s.byID[id]
+	return snap, ok
+}
+
+// pushProfiler reports to a Pyroscope server in continuous push mode.
+type pushProfiler struct {
+	cfg     ProfilerConfig
+	started *pyroscope.Profiler
+	store   *snapshotStore
+}
+
+func (p *pushProfiler) Start() error {
+	profiler, err := pyroscope.Start(pyroscope.Config{
+		ApplicationName: p.cfg.ApplicationName,
+		ServerAddress:   p.cfg.ServerAddress,
+		AuthToken:       p.cfg.AuthToken,
+		Tags:            p.cfg.Tags,
+		UploadRate:      p.cfg.UploadRate,
+		ProfileTypes:    pyroProfileTypes(p.cfg.ProfileTypes),
+	})
+	p.started = profiler
+
+	return err
+}
+
+func (p *pushProfiler) Stop() error {
+	if p.started == nil {
+		return nil
+	}
+
+	return p.started.Stop()
+}
+
+func (p *pushProfiler) Snapshot(ctx context.Context) (string, error) {
+	// snapshotMu doesn't cover pyroscope-go's own background collector, so a
+	// collision here surfaces as ErrProfilingBusy rather than a hard failure.
+	snap, err := takeSnapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p.store.put(snap)
+
+	return snap.id, nil
+}
+
+// pullProfiler leaves continuous collection to an external Pyroscope/Parca
+// instance scraping the pprof endpoints that EnablePProfEndpoint registers.
+// Start only logs the configured tags; it registers with no discovery service.
+type pullProfiler struct {
+	cfg   ProfilerConfig
+	store *snapshotStore
+}
+
+func (p *pullProfiler) Start() error {
+	logger.Debugw("Pull-mode profiling enabled", "tags", p.cfg.Tags)
+	return nil
+}
+
+func (p *pullProfiler) Stop() error {
+	return nil
+}
+
+func (p *pullProfiler) Snapshot(ctx context.Context) (string, error) {
+	snap, err := takeSnapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p.store.put(snap)
+
+	return snap.id, nil
+}
+
+// otlpProfiler ships pprof snapshots over OTLP/HTTP to a collector.
+type otlpProfiler struct {
+	cfg    ProfilerConfig
+	client http.Client
+}
+
+func (p *otlpProfiler) Start() error {
+	return nil
+}
+
+func (p *otlpProfiler) Stop() error {
+	return nil
+}
+
+func (p *otlpProfiler) Snapshot(ctx context.Context) (string, error) {
+	snap, err := takeSnapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.upload(ctx, snap.id, "cpu", snap.cpu); err != nil {
+		return "", err
+	}
+
+	if err := p.upload(ctx, snap.id, "heap", snap.heap); err != nil {
+		return "", err
+	}
+
+	return snap.id, nil
+}
+
+// upload ships one profile of the pair to the OTLP collector, tagged with
+// the snapshot ID and its kind ("cpu" or "heap") as query parameters.
+func (p *otlpProfiler) upload(ctx context.Context, id, kind string, profile []byte) error {
+	url := fmt.Sprintf("%s/v1development/profiles?snapshot_id=%s&profile_type=%s", p.cfg.OTLPEndpoint, id, kind)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(profile))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s profile to otlp collector: %w", kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp collector rejected %s profile: status %d", kind, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// pyroProfileTypes maps configured profile type names onto pyroscope's enum.
+func pyroProfileTypes(names []string) []pyroscope.ProfileType {
+	if len(names) == 0 {
+		return pyroscope.DefaultProfileTypes
+	}
+
+	types := make([]pyroscope.ProfileType, 0, len(names))
+	for _, name := range names {
+		types = append(types, pyroscope.ProfileType(name))
+	}
+
+	return types
+}
+
+// EnableProfiler starts the Profiler backend selected by cfg and, when the
+// Server's Config.PprofAddr is set, registers POST /debug/pprof/snapshot and
+// GET /debug/pprof/snapshot/{id} on it. If
+// cfg.ServerAddress is unset, it falls back to the Server's own
+// Config.PyroscopeAddr.
+func (s *Server) EnableProfiler(cfg ProfilerConfig) error {
+	if cfg.ServerAddress == "" {
+		cfg.ServerAddress = s.cfg.PyroscopeAddr
+	}
+
+	s.profiler = newProfiler(cfg, &s.snapshots)
+
+	if s.cfg.PprofAddr != "" {
+		mux := s.muxFor(s.cfg.PprofAddr)
+		mux.HandleFunc("/debug/pprof/snapshot", s.handleSnapshot)
+		mux.HandleFunc("/debug/pprof/snapshot/", s.handleSnapshotDownload)
+	}
+
+	return s.profiler.Start()
+}
+
+// handleSnapshot triggers an ad-hoc CPU+heap capture via the configured
+// Profiler and returns the resulting profile ID.
+func (s *Server) handleSnapshot(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.profiler == nil {
+		http.Error(w, "no profiler backend configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := s.profiler.Snapshot(req.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrProfilingBusy) {
+			status = http.StatusServiceUnavailable
+		}
+
+		logger.Errorw("Capturing profile snapshot", "error", err)
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	fmt.Fprintf(w, "%s", id)
+}
+
+// handleSnapshotDownload serves back a profile captured by handleSnapshot,
+// e.g. GET /debug/pprof/snapshot/<id>?type=cpu|heap (type defaults to cpu).
+// Only the locally stored backends (push, pull) keep a copy here; the OTLP
+// backend's profiles live on the configured collector instead.
+func (s *Server) handleSnapshotDownload(w http.ResponseWriter, req *http.Request) {
+	id := req.URL.Path[len("/debug/pprof/snapshot/"):]
+	if id == "" {
+		http.NotFound(w, req)
+		return
+	}
+
+	snap, ok := s.snapshots.get(id)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	profile := snap.cpu
+	if req.URL.Query().Get("type") == "heap" {
+		profile = snap.heap
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(profile)
+}
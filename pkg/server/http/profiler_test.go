@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotStorePutGet(t *testing.T) {
+	var store snapshotStore
+
+	if _, ok := store.get("missing"); ok {
+		t.Fatalf("get() on empty store returned ok=true")
+	}
+
+	snap := snapshot{id: "abc", cpu: []byte("cpu"), heap: []byte("heap")}
+	store.put(snap)
+
+	got, ok := store.get("abc")
+	if !ok {
+		t.Fatalf("get() after put returned ok=false")
+	}
+	if string(got.cpu) != "cpu" || string(got.heap) != "heap" {
+		t.Errorf("get() = %+v, want %+v", got, snap)
+	}
+}
+
+// TestTakeSnapshotConcurrent exercises the bug this test was added to catch:
+// two goroutines calling takeSnapshot at once used to race on
+// runtime/pprof's single global CPU profile, with the loser getting a bare
+// "cpu profiling already in use" error. snapshotMu now serializes them, so
+// none of the n concurrent calls should fail.
+func TestTakeSnapshotConcurrent(t *testing.T) {
+	const n = 4
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// cancel immediately: the test only cares about StartCPUProfile
+			// coordination, not the full snapshotCPUDuration wait.
+			cancel()
+
+			_, err := takeSnapshot(ctx)
+			errs[i] = err
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("takeSnapshot() call %d: %v", i, err)
+		}
+		if errors.Is(err, ErrProfilingBusy) {
+			t.Errorf("takeSnapshot() call %d returned ErrProfilingBusy despite snapshotMu", i)
+		}
+	}
+}
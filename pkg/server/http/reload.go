@@ -0,0 +1,42 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// ReloadFunc re-reads the config file and applies changes to mutable
+// settings owned outside this package, returning the reloaded pprof rates
+// for EnableReloadEndpoint to apply itself.
+type ReloadFunc func(ctx context.Context) (mutexProfileFraction, blockProfileRate int, err error)
+
+// EnableReloadEndpoint registers POST /debug/config/reload on cfg.PprofAddr.
+// reload is invoked on every request; its returned pprof rates are applied
+// via SetPprofRates.
+func (s *Server) EnableReloadEndpoint(reload ReloadFunc) {
+	if s.cfg.PprofAddr == "" {
+		return
+	}
+
+	s.muxFor(s.cfg.PprofAddr).HandleFunc("/debug/config/reload", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mutexProfileFraction, blockProfileRate, err := reload(req.Context())
+		if err != nil {
+			logger.Errorw("Reloading config", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		s.SetPprofRates(mutexProfileFraction, blockProfileRate)
+
+		fmt.Fprintf(w, "OK")
+	})
+}
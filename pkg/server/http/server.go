@@ -2,130 +2,287 @@ package http
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"runtime"
+	"sync"
 	"time"
 
-	"github.com/grafana/pyroscope-go"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/aquasecurity/tracee/pkg/ebpf/heartbeat"
+	"github.com/aquasecurity/tracee/pkg/envutil"
 	"github.com/aquasecurity/tracee/pkg/logger"
 )
 
-// interval defines how often the heartbeat signal should be sent.
-const heartbeatSignalInterval = time.Duration(1 * time.Second)
+// defaultShutdownTimeout bounds how long Shutdown waits for active
+// connections to drain before the listeners are forcibly closed.
+const defaultShutdownTimeout = 5 * time.Second
 
-// timeout specifies the maximum duration to wait for a heartbeat acknowledgment
-const heartbeatAckTimeout = time.Duration(2 * time.Second)
+// Config holds the settings for Tracee's HTTP subsystem. An empty address
+// disables the corresponding endpoint.
+type Config struct {
+	// ListenAddr is the address public traffic is served on.
+	ListenAddr string
+	// MetricsAddr is the address /metrics is served on. Empty disables it.
+	MetricsAddr string
+	// PprofAddr is the address /debug/pprof/* is served on. Empty disables it.
+	PprofAddr string
+	// PyroscopeAddr is the Pyroscope server address EnableProfiler uses when
+	// ProfilerConfig.ServerAddress is unset. Leaving it empty does not
+	// disable profiling.
+	PyroscopeAddr string
+	// ReadTimeout is the maximum duration for reading an entire request, on every listener.
+	ReadTimeout time.Duration
+	// WriteTimeout is the maximum duration before timing out writes of the response.
+	WriteTimeout time.Duration
+	// ShutdownTimeout bounds how long Shutdown waits for active connections to drain.
+	ShutdownTimeout time.Duration
+	// MutexProfileFraction is passed to runtime.SetMutexProfileFraction when
+	// pprof is enabled. 0 leaves the runtime default in place.
+	MutexProfileFraction int
+	// BlockProfileRate is passed to runtime.SetBlockProfileRate when pprof is
+	// enabled. 0 leaves the runtime default in place.
+	BlockProfileRate int
+}
+
+// WithEnvOverrides returns a copy of cfg with every field overridable by a
+// TRACEE_HTTP_* environment variable.
+func (c Config) WithEnvOverrides() Config {
+	c.ListenAddr = envutil.String("TRACEE_HTTP_LISTEN_ADDR", c.ListenAddr)
+	c.MetricsAddr = envutil.String("TRACEE_HTTP_METRICS_ADDR", c.MetricsAddr)
+	c.PprofAddr = envutil.String("TRACEE_HTTP_PPROF_ADDR", c.PprofAddr)
+	c.PyroscopeAddr = envutil.String("TRACEE_HTTP_PYROSCOPE_ADDR", c.PyroscopeAddr)
+	c.ReadTimeout = envutil.Duration("TRACEE_HTTP_READ_TIMEOUT", c.ReadTimeout)
+	c.WriteTimeout = envutil.Duration("TRACEE_HTTP_WRITE_TIMEOUT", c.WriteTimeout)
+	c.ShutdownTimeout = envutil.Duration("TRACEE_HTTP_SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	c.MutexProfileFraction = envutil.Int("TRACEE_HTTP_PPROF_MUTEX_FRACTION", c.MutexProfileFraction)
+	c.BlockProfileRate = envutil.Int("TRACEE_HTTP_PPROF_BLOCK_RATE", c.BlockProfileRate)
+
+	return c
+}
+
+// listener pairs a http.Server with the mux it serves, so that endpoints
+// configured with the same address share a single listening socket.
+type listener struct {
+	hs  *http.Server
+	mux *http.ServeMux
+}
 
 // Server represents a http server
 type Server struct {
-	hs             *http.Server
-	mux            *http.ServeMux // just an exposed copy of hs.Handler
-	metricsEnabled bool
-	pyroProfiler   *pyroscope.Profiler
+	cfg             Config
+	listeners       map[string]*listener // keyed by address
+	metricsEnabled  bool
+	profiler        Profiler
+	shutdownTimeout time.Duration
+	liveness        healthRegistry
+	readiness       healthRegistry
+	heartbeatProbes []*heartbeat.Probe
+	snapshots       snapshotStore
+	pprofRatesMu    sync.Mutex // guards cfg.MutexProfileFraction and cfg.BlockProfileRate
 }
 
-// New creates a new server
-func New(listenAddr string) *Server {
-	mux := http.NewServeMux()
+// New creates a new server from cfg, with TRACEE_HTTP_* environment
+// variables applied on top.
+func New(cfg Config) *Server {
+	cfg = cfg.WithEnvOverrides()
+
+	s := &Server{
+		cfg:             cfg,
+		listeners:       make(map[string]*listener),
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
 
-	return &Server{
+	if s.shutdownTimeout == 0 {
+		s.shutdownTimeout = defaultShutdownTimeout
+	}
+
+	return s
+}
+
+// muxFor returns the ServeMux serving addr, creating its listener on first use.
+func (s *Server) muxFor(addr string) *http.ServeMux {
+	if l, ok := s.listeners[addr]; ok {
+		return l.mux
+	}
+
+	mux := http.NewServeMux()
+	s.listeners[addr] = &listener{
+		mux: mux,
 		hs: &http.Server{
-			Addr:    listenAddr,
-			Handler: mux,
+			Addr:         addr,
+			Handler:      mux,
+			ReadTimeout:  s.cfg.ReadTimeout,
+			WriteTimeout: s.cfg.WriteTimeout,
 		},
-		mux: mux,
 	}
+
+	return mux
 }
 
-// EnableMetricsEndpoint enables metrics endpoint
+// EnableMetricsEndpoint enables the metrics endpoint on cfg.MetricsAddr.
 func (s *Server) EnableMetricsEndpoint() {
-	s.mux.Handle("/metrics", promhttp.Handler())
+	if s.cfg.MetricsAddr == "" {
+		return
+	}
+
+	s.muxFor(s.cfg.MetricsAddr).Handle("/metrics", promhttp.HandlerFor(
+		prometheus.DefaultGatherer,
+		promhttp.HandlerOpts{EnableOpenMetrics: true},
+	))
 	s.metricsEnabled = true
 }
 
-// EnableHealthzEndpoint enables healthz endpoint
+// EnableHealthzEndpoint enables the /healthz (liveness) and /readyz
+// (readiness) endpoints on cfg.ListenAddr.
 func (s *Server) EnableHealthzEndpoint() {
-	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
-		if heartbeat.GetInstance() != nil && heartbeat.GetInstance().IsAlive() {
-			fmt.Fprintf(w, "OK")
-			return
-		}
-		fmt.Fprintf(w, "NOT OK")
-	})
+	if s.cfg.ListenAddr == "" {
+		return
+	}
+
+	mux := s.muxFor(s.cfg.ListenAddr)
+	mux.Handle("/healthz", &s.liveness)
+	mux.Handle("/readyz", &s.readiness)
+}
+
+// RegisterReadinessCheck adds checker to the set of subsystems that must be ready for /readyz to pass.
+func (s *Server) RegisterReadinessCheck(checker HealthChecker) {
+	s.readiness.register(checker)
+}
+
+// RegisterLivenessCheck adds checker to the set that /healthz reports on,
+// in addition to the registered heartbeat probes.
+func (s *Server) RegisterLivenessCheck(checker HealthChecker) {
+	s.liveness.register(checker)
+}
+
+// RegisterHeartbeatProbe wires probe into both the heartbeat started by
+// Start and the /healthz liveness registry.
+func (s *Server) RegisterHeartbeatProbe(probe *heartbeat.Probe) {
+	s.heartbeatProbes = append(s.heartbeatProbes, probe)
+	s.liveness.register(heartbeatProbeChecker{probe: probe})
+}
+
+// EnablePProfEndpoint enables pprof endpoints on cfg.PprofAddr for
+// debugging, and applies cfg.MutexProfileFraction/BlockProfileRate.
+func (s *Server) EnablePProfEndpoint() {
+	if s.cfg.PprofAddr == "" {
+		return
+	}
+
+	s.applyPprofRates()
+
+	mux := s.muxFor(s.cfg.PprofAddr)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.Handle("/debug/pprof/allocs", pprof.Handler("allocs"))
+	mux.Handle("/debug/pprof/block", pprof.Handler("block"))
+	mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+	mux.Handle("/debug/pprof/mutex", pprof.Handler("mutex"))
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", handleStdCPUProfile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// handleStdCPUProfile wraps the stdlib /debug/pprof/profile handler with
+// snapshotMu, so it serializes with /debug/pprof/snapshot.
+func handleStdCPUProfile(w http.ResponseWriter, req *http.Request) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+
+	pprof.Profile(w, req)
 }
 
-// Start starts the http server on the listen address
+// applyPprofRates pushes the configured mutex/block profiling rates into
+// the runtime. pprofRatesMu is held across the runtime.Set* calls, not just
+// the cfg read, so concurrent reloads can't apply their rates out of order.
+func (s *Server) applyPprofRates() {
+	s.pprofRatesMu.Lock()
+	defer s.pprofRatesMu.Unlock()
+
+	runtime.SetMutexProfileFraction(s.cfg.MutexProfileFraction)
+	runtime.SetBlockProfileRate(s.cfg.BlockProfileRate)
+}
+
+// SetPprofRates updates the mutex/block profiling rates and immediately
+// applies them. Safe to call concurrently.
+func (s *Server) SetPprofRates(mutexProfileFraction, blockProfileRate int) {
+	s.pprofRatesMu.Lock()
+	s.cfg.MutexProfileFraction = mutexProfileFraction
+	s.cfg.BlockProfileRate = blockProfileRate
+	s.pprofRatesMu.Unlock()
+
+	s.applyPprofRates()
+}
+
+// MetricsEndpointEnabled returns true if metrics endpoint is enabled
+func (s *Server) MetricsEndpointEnabled() bool {
+	return s.metricsEnabled
+}
+
+// Start starts all configured listeners and the heartbeat goroutine. It
+// blocks until ctx is done or a listener fails, then shuts everything down.
 func (s *Server) Start(ctx context.Context) {
 	srvCtx, srvCancel := context.WithCancel(ctx)
 	defer srvCancel()
 
-	go func() {
-		logger.Debugw("Starting serving metrics endpoint goroutine", "address", s.hs.Addr)
-		defer logger.Debugw("Stopped serving metrics endpoint goroutine")
+	for addr, l := range s.listeners {
+		l := l
+		logger.Debugw("Starting serving http endpoint goroutine", "address", addr)
 
-		if err := s.hs.ListenAndServe(); err != http.ErrServerClosed {
-			logger.Errorw("Serving metrics endpoint", "error", err)
-		}
+		go func() {
+			defer logger.Debugw("Stopped serving http endpoint goroutine", "address", l.hs.Addr)
 
-		srvCancel()
-	}()
+			if err := l.hs.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Errorw("Serving http endpoint", "address", l.hs.Addr, "error", err)
+			}
+
+			srvCancel()
+		}()
+	}
 
 	heartbeatCtx, cancel := context.WithCancel(srvCtx)
 	defer cancel()
 
-	heartbeat.Init(heartbeatCtx, heartbeatSignalInterval, heartbeatAckTimeout)
-	heartbeat.GetInstance().SetCallback(invokeHeartbeat)
-	heartbeat.GetInstance().Start()
+	heartbeat.Init(heartbeatCtx, s.heartbeatProbes...)
 
 	select {
 	case <-ctx.Done():
-		logger.Debugw("Context cancelled, shutting down metrics endpoint server")
-		if err := s.hs.Shutdown(ctx); err != nil {
-			logger.Errorw("Stopping serving metrics endpoint", "error", err)
-		}
+		logger.Debugw("Context cancelled, shutting down http server")
 
-	// if server error occurred while base ctx is not done, we should exit via this case
+	// a listener failed while base ctx is not done; the other listeners are
+	// still running goroutines, so fall through to the same shutdown path
+	// rather than leaking them.
 	case <-srvCtx.Done():
+		logger.Debugw("A listener stopped, shutting down the remaining http server(s)")
 	}
-}
 
-// EnablePProfEndpoint enables pprof endpoint for debugging
-func (s *Server) EnablePProfEndpoint() {
-	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
-	s.mux.Handle("/debug/pprof/allocs", pprof.Handler("allocs"))
-	s.mux.Handle("/debug/pprof/block", pprof.Handler("block"))
-	s.mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
-	s.mux.Handle("/debug/pprof/mutex", pprof.Handler("mutex"))
-	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
-}
-
-// EnablePyroAgent enables pyroscope agent in golang push mode
-// TODO: make this configurable
-func (s *Server) EnablePyroAgent() error {
-	p, err := pyroscope.Start(
-		pyroscope.Config{
-			ApplicationName: "tracee",
-			ServerAddress:   "http://localhost:4040",
-		},
-	)
-	s.pyroProfiler = p
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer shutdownCancel()
 
-	return err
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logger.Errorw("Stopping http server", "error", err)
+	}
 }
 
-// MetricsEndpointEnabled returns true if metrics endpoint is enabled
-func (s *Server) MetricsEndpointEnabled() bool {
-	return s.metricsEnabled
-}
+// Shutdown gracefully stops all listeners, waiting up to ctx's deadline for
+// active connections to drain.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var firstErr error
+
+	if s.profiler != nil {
+		if err := s.profiler.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, l := range s.listeners {
+		if err := l.hs.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 
-//go:noinline
-func invokeHeartbeat() {
-	// Intentionally left empty
+	return firstErr
 }
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSetPprofRatesConcurrent exercises the bug this test was added to
+// catch: concurrent POST /debug/config/reload requests used to race on
+// Config.MutexProfileFraction/BlockProfileRate with no locking. Run with
+// -race to confirm pprofRatesMu actually guards them.
+func TestSetPprofRatesConcurrent(t *testing.T) {
+	s := New(Config{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.SetPprofRates(i, i)
+		}(i)
+	}
+	wg.Wait()
+}